@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Config is the top-level structure of the --config.file used by the
+// multi-target /probe endpoint. It maps a short auth module name to the
+// credentials and connection defaults used to reach a given MySQL server.
+type Config struct {
+	AuthModules map[string]AuthModule `yaml:"auth_modules"`
+}
+
+// AuthModule describes how to authenticate against a target. Only the
+// "userpass" type is currently supported, mirroring the credentials found
+// in a .my.cnf [client] section. Host, Port and Socket are optional default
+// connection params: Socket, if set, pins every probe using this module to
+// that local unix socket regardless of ?target=; otherwise Host/Port, if
+// set, override the host/port parsed out of ?target= (e.g. a module that
+// always talks to a fixed replica on a non-standard port).
+type AuthModule struct {
+	Type     string    `yaml:"type"`
+	UserPass UserPass  `yaml:"userpass,omitempty"`
+	TLS      TLSConfig `yaml:"tls,omitempty"`
+	Host     string    `yaml:"host,omitempty"`
+	Port     uint16    `yaml:"port,omitempty"`
+	Socket   string    `yaml:"socket,omitempty"`
+}
+
+// UserPass holds username/password credentials for the "userpass" auth
+// module type.
+type UserPass struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// TLSConfig controls whether and how the exporter verifies the target's
+// certificate when connecting over TLS.
+type TLSConfig struct {
+	CAFile             string `yaml:"ca_file,omitempty"`
+	CertFile           string `yaml:"cert_file,omitempty"`
+	KeyFile            string `yaml:"key_file,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"`
+}
+
+// LoadConfig reads and parses the YAML config file at path.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file %s: %s", path, err)
+	}
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("error parsing config file %s: %s", path, err)
+	}
+	return cfg, nil
+}
+
+// DSN builds a go-sql-driver DSN for target using the named auth module. If
+// the module sets Socket, every probe using it connects to that unix socket
+// regardless of target. Otherwise target supplies the host/port to dial,
+// with the module's Host/Port, if set, overriding the corresponding piece
+// of target.
+func (c *Config) DSN(authModuleName, target string) (string, error) {
+	module, ok := c.AuthModules[authModuleName]
+	if !ok {
+		return "", fmt.Errorf("auth module %q not found in config file", authModuleName)
+	}
+	if module.Type != "userpass" {
+		return "", fmt.Errorf("unsupported auth module type %q for %q", module.Type, authModuleName)
+	}
+	if module.UserPass.Username == "" {
+		return "", fmt.Errorf("auth module %q has no userpass.username", authModuleName)
+	}
+
+	params := "/"
+	if module.TLS.CAFile != "" || module.TLS.CertFile != "" || module.TLS.InsecureSkipVerify {
+		if err := registerTLSConfig(authModuleName, module.TLS); err != nil {
+			return "", err
+		}
+		params += "?tls=" + authModuleName
+	}
+
+	if module.Socket != "" {
+		return fmt.Sprintf("%s:%s@unix(%s)%s", module.UserPass.Username, module.UserPass.Password, module.Socket, params), nil
+	}
+
+	addr, err := probeAddress(target, module)
+	if err != nil {
+		return "", fmt.Errorf("auth module %q: %w", authModuleName, err)
+	}
+	return fmt.Sprintf("%s:%s@tcp(%s)%s", module.UserPass.Username, module.UserPass.Password, addr, params), nil
+}
+
+// probeAddress resolves the host:port to dial for a probe, starting from
+// target and letting the auth module's Host/Port, if set, override the
+// corresponding piece.
+func probeAddress(target string, module AuthModule) (string, error) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		host, port = strings.TrimSuffix(strings.TrimPrefix(target, "["), "]"), ""
+	}
+	if module.Host != "" {
+		host = module.Host
+	}
+	if module.Port != 0 {
+		port = strconv.Itoa(int(module.Port))
+	}
+	if host == "" {
+		return "", fmt.Errorf("no host to connect to for target %q", target)
+	}
+	if port == "" {
+		port = "3306"
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// registerTLSConfig registers a go-sql-driver TLS config under name so it
+// can be referenced from a DSN's "tls" query parameter.
+func registerTLSConfig(name string, cfg TLSConfig) error {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+
+	if cfg.CAFile != "" {
+		rootCertPool := x509.NewCertPool()
+		pem, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return fmt.Errorf("error reading CA file %s: %s", cfg.CAFile, err)
+		}
+		if !rootCertPool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("failed to parse CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = rootCertPool
+	}
+
+	if cfg.CertFile != "" && cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return fmt.Errorf("error loading client cert/key: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return mysql.RegisterTLSConfig(name, tlsConfig)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	configLastReloadSuccessful = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: exporter,
+		Name:      "config_last_reload_successful",
+		Help:      "Whether the last configuration file reload attempt succeeded.",
+	})
+	configLastReloadSuccessTimestampSeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: exporter,
+		Name:      "config_last_reload_success_timestamp_seconds",
+		Help:      "Timestamp of the last successful configuration file reload.",
+	})
+	configLoadsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: exporter,
+		Name:      "config_loads_total",
+		Help:      "Number of attempts to load the configuration file, by result.",
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(configLastReloadSuccessful, configLastReloadSuccessTimestampSeconds, configLoadsTotal)
+}
+
+// ConfigReloader owns the live auth_modules Config used by the /probe
+// endpoint and keeps it current as the backing file changes, via SIGHUP, a
+// filesystem watch, or POST /-/reload. Reload swaps the parsed Config in
+// under a mutex, so an in-flight /probe request always sees either the old
+// or the new config in full, never a half-updated one; a bad file is
+// reported but leaves the previous config live.
+type ConfigReloader struct {
+	path   string
+	logger *slog.Logger
+
+	mu  sync.RWMutex
+	cfg *Config
+}
+
+// NewConfigReloader loads path and returns a ConfigReloader serving it.
+func NewConfigReloader(path string, logger *slog.Logger) (*ConfigReloader, error) {
+	r := &ConfigReloader{path: path, logger: logger}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Get returns the currently active Config.
+func (r *ConfigReloader) Get() *Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cfg
+}
+
+// Reload re-reads and parses the config file, swapping it in only if it
+// parses successfully.
+func (r *ConfigReloader) Reload() error {
+	cfg, err := LoadConfig(r.path)
+	if err != nil {
+		configLoadsTotal.WithLabelValues("failure").Inc()
+		configLastReloadSuccessful.Set(0)
+		r.logger.Error("error reloading config", "path", r.path, "err", err)
+		return err
+	}
+
+	r.mu.Lock()
+	r.cfg = cfg
+	r.mu.Unlock()
+
+	configLoadsTotal.WithLabelValues("success").Inc()
+	configLastReloadSuccessful.Set(1)
+	configLastReloadSuccessTimestampSeconds.SetToCurrentTime()
+	r.logger.Info("loaded config file", "path", r.path)
+	return nil
+}
+
+// Watch reloads the config on SIGHUP and whenever the file changes on
+// disk, until ctx is cancelled. It watches the containing directory rather
+// than the file itself so reloads keep working after an editor replaces
+// the file via rename-and-swap.
+func (r *ConfigReloader) Watch(ctx context.Context) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		r.logger.Error("error creating config file watcher", "err", err)
+		return
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(filepath.Dir(r.path)); err != nil {
+		r.logger.Error("error watching config directory", "err", err)
+		return
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-hup:
+			r.logger.Info("received signal, reloading config", "signal", sig.String())
+			r.Reload()
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != filepath.Clean(r.path) {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			r.logger.Info("config file changed, reloading")
+			r.Reload()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			r.logger.Error("config watcher error", "err", err)
+		}
+	}
+}
+
+// ReloadHandler implements POST /-/reload.
+func (r *ConfigReloader) ReloadHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.Reload(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func Test_ConfigReloaderAtomicSwap(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yml")
+
+	good := `
+auth_modules:
+  client:
+    type: userpass
+    userpass:
+      username: root
+      password: abc123
+`
+	if err := os.WriteFile(path, []byte(good), 0644); err != nil {
+		t.Fatalf("error writing config file: %s", err)
+	}
+
+	reloader, err := NewConfigReloader(path, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("error loading initial config: %s", err)
+	}
+
+	convey.Convey("A bad reload keeps the previous config live", t, func() {
+		before := reloader.Get()
+
+		if err := os.WriteFile(path, []byte("not: [valid"), 0644); err != nil {
+			t.Fatalf("error writing bad config file: %s", err)
+		}
+		convey.So(reloader.Reload(), convey.ShouldNotBeNil)
+		convey.So(reloader.Get(), convey.ShouldEqual, before)
+	})
+
+	convey.Convey("A good reload swaps in the new config", t, func() {
+		updated := `
+auth_modules:
+  client:
+    type: userpass
+    userpass:
+      username: other
+      password: xyz789
+`
+		if err := os.WriteFile(path, []byte(updated), 0644); err != nil {
+			t.Fatalf("error writing updated config file: %s", err)
+		}
+		convey.So(reloader.Reload(), convey.ShouldBeNil)
+		convey.So(reloader.Get().AuthModules["client"].UserPass.Username, convey.ShouldEqual, "other")
+	})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func Test_ConfigDSN(t *testing.T) {
+	convey.Convey("Given a config with several auth modules", t, func() {
+		cfg := &Config{AuthModules: map[string]AuthModule{
+			"plain": {
+				Type:     "userpass",
+				UserPass: UserPass{Username: "root", Password: "abc123"},
+			},
+			"fixed_port": {
+				Type:     "userpass",
+				UserPass: UserPass{Username: "root", Password: "abc123"},
+				Port:     3307,
+			},
+			"pinned_host": {
+				Type:     "userpass",
+				UserPass: UserPass{Username: "root", Password: "abc123"},
+				Host:     "replica.internal",
+			},
+			"socket": {
+				Type:     "userpass",
+				UserPass: UserPass{Username: "root", Password: "abc123"},
+				Socket:   "/var/lib/mysql/mysql.sock",
+			},
+		}}
+
+		convey.Convey("Plain module uses target as-is", func() {
+			dsn, err := cfg.DSN("plain", "db.example.com:3306")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(dsn, convey.ShouldEqual, "root:abc123@tcp(db.example.com:3306)/")
+		})
+
+		convey.Convey("Target without a port defaults to 3306", func() {
+			dsn, err := cfg.DSN("plain", "db.example.com")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(dsn, convey.ShouldEqual, "root:abc123@tcp(db.example.com:3306)/")
+		})
+
+		convey.Convey("Module port overrides the target's port", func() {
+			dsn, err := cfg.DSN("fixed_port", "db.example.com:3306")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(dsn, convey.ShouldEqual, "root:abc123@tcp(db.example.com:3307)/")
+		})
+
+		convey.Convey("Module host overrides the target's host", func() {
+			dsn, err := cfg.DSN("pinned_host", "db.example.com:3306")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(dsn, convey.ShouldEqual, "root:abc123@tcp(replica.internal:3306)/")
+		})
+
+		convey.Convey("Module socket overrides target entirely", func() {
+			dsn, err := cfg.DSN("socket", "db.example.com:3306")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(dsn, convey.ShouldEqual, "root:abc123@unix(/var/lib/mysql/mysql.sock)/")
+		})
+
+		convey.Convey("Bracketed IPv6 target without a port is not double-bracketed", func() {
+			dsn, err := cfg.DSN("plain", "[::1]")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(dsn, convey.ShouldEqual, "root:abc123@tcp([::1]:3306)/")
+		})
+	})
+}
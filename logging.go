@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// debugLogBufferSize bounds how many recent log lines the /debug/log
+// endpoint keeps in memory.
+const debugLogBufferSize = 500
+
+// parseLogLevel maps a --log.level value to an slog.Level, defaulting to
+// info for anything unrecognized.
+func parseLogLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// newLogger builds the process-wide structured logger from --log.format and
+// --log.level, tee'ing every record into buf so /debug/log can replay the
+// recent log lines for a specific request.
+func newLogger(format, level string, buf *debugLogBuffer) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: parseLogLevel(level)}
+
+	var handler slog.Handler
+	switch format {
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	}
+	return slog.New(newRingHandler(handler, buf))
+}
+
+// newTraceID returns a short random hex ID to correlate the log lines of a
+// single /probe request, echoed back to the caller in the X-Trace-Id
+// response header.
+func newTraceID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// debugLogBuffer is a fixed-size ring of recently logged lines, so a
+// specific /probe request can be re-examined after the fact via the
+// trace_id it was given, without having to grep the process' own stderr.
+type debugLogBuffer struct {
+	mu     sync.Mutex
+	lines  []string
+	next   int
+	filled bool
+}
+
+func newDebugLogBuffer(size int) *debugLogBuffer {
+	return &debugLogBuffer{lines: make([]string, size)}
+}
+
+func (b *debugLogBuffer) add(line string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lines[b.next] = line
+	b.next = (b.next + 1) % len(b.lines)
+	if b.next == 0 {
+		b.filled = true
+	}
+}
+
+// recent returns the buffered lines oldest-first, optionally narrowed to
+// those carrying trace_id=<traceID>.
+func (b *debugLogBuffer) recent(traceID string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ordered := make([]string, 0, len(b.lines))
+	if b.filled {
+		ordered = append(ordered, b.lines[b.next:]...)
+	}
+	ordered = append(ordered, b.lines[:b.next]...)
+
+	if traceID == "" {
+		return ordered
+	}
+	needle := "trace_id=" + traceID
+	filtered := ordered[:0]
+	for _, line := range ordered {
+		if strings.Contains(line, needle) {
+			filtered = append(filtered, line)
+		}
+	}
+	return filtered
+}
+
+// ServeHTTP implements GET /debug/log. An optional ?trace_id= narrows the
+// output to the log lines of one /probe request, e.g. the ID echoed back in
+// its X-Trace-Id response header.
+func (b *debugLogBuffer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, line := range b.recent(r.URL.Query().Get("trace_id")) {
+		fmt.Fprintln(w, line)
+	}
+}
+
+// ringHandler is an slog.Handler that formats each record as a single
+// logfmt-style line and appends it to buf, in addition to passing it on to
+// next for normal output.
+type ringHandler struct {
+	next  slog.Handler
+	buf   *debugLogBuffer
+	attrs []slog.Attr
+}
+
+func newRingHandler(next slog.Handler, buf *debugLogBuffer) *ringHandler {
+	return &ringHandler{next: next, buf: buf}
+}
+
+func (h *ringHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *ringHandler) Handle(ctx context.Context, r slog.Record) error {
+	var sb strings.Builder
+	sb.WriteString(r.Time.Format(time.RFC3339))
+	sb.WriteString(" level=")
+	sb.WriteString(r.Level.String())
+	sb.WriteString(" msg=")
+	sb.WriteString(strconv.Quote(r.Message))
+	for _, a := range h.attrs {
+		fmt.Fprintf(&sb, " %s=%v", a.Key, a.Value.Any())
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		fmt.Fprintf(&sb, " %s=%v", a.Key, a.Value.Any())
+		return true
+	})
+	h.buf.add(sb.String())
+
+	return h.next.Handle(ctx, r)
+}
+
+func (h *ringHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	merged := make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	merged = append(merged, h.attrs...)
+	merged = append(merged, attrs...)
+	return &ringHandler{next: h.next.WithAttrs(attrs), buf: h.buf, attrs: merged}
+}
+
+func (h *ringHandler) WithGroup(name string) slog.Handler {
+	return &ringHandler{next: h.next.WithGroup(name), buf: h.buf, attrs: h.attrs}
+}
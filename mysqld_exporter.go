@@ -0,0 +1,720 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/version"
+	"gopkg.in/alecthomas/kingpin.v2"
+	ini "gopkg.in/ini.v1"
+)
+
+const (
+	namespace         = "mysql"
+	informationSchema = "info_schema"
+	globalStatus      = "global_status"
+	exporter          = "exporter"
+)
+
+var (
+	listenAddress = kingpin.Flag(
+		"web.listen-address", "Address to listen on for web interface and telemetry.",
+	).Default(":9104").String()
+	metricsPath = kingpin.Flag(
+		"web.telemetry-path", "Path under which to expose metrics.",
+	).Default("/metrics").String()
+	configMycnf = kingpin.Flag(
+		"config.my-cnf", "Path to .my.cnf file to read MySQL credentials from.",
+	).Default(path.Join(os.Getenv("HOME"), ".my.cnf")).String()
+	configFile = kingpin.Flag(
+		"config.file", "Path to a YAML config file defining auth_modules for the multi-target /probe endpoint.",
+	).Default("").String()
+	scrapeTimeoutOffset = kingpin.Flag(
+		"scrape.timeout-offset", "Offset to subtract from the Prometheus-supplied timeout, in seconds, so the exporter can reply before Prometheus' own scrape_timeout fires.",
+	).Default("0.25").Float64()
+	globalStatusTimeout = kingpin.Flag(
+		"collect.global_status.timeout", "Maximum time to allow the global_status collector to run; 0 uses the overall scrape deadline.",
+	).Default("0s").Duration()
+	tableStatTimeout = kingpin.Flag(
+		"collect.info_schema.tablestats.timeout", "Maximum time to allow the info_schema.tablestats collector to run; 0 uses the overall scrape deadline.",
+	).Default("0s").Duration()
+	queryResponseTimeTimeout = kingpin.Flag(
+		"collect.info_schema.query_response_time.timeout", "Maximum time to allow the info_schema.query_response_time collector to run; 0 uses the overall scrape deadline.",
+	).Default("0s").Duration()
+	perfStatementsTimeout = kingpin.Flag(
+		"collect.perf_schema.statements.timeout", "Maximum time to allow the perf_schema.statements collector to run; 0 uses the overall scrape deadline.",
+	).Default("0s").Duration()
+	perfStatementsLimit = kingpin.Flag(
+		"collect.perf_schema.statements.limit", "Maximum number of statement digests to collect, ranked by SUM_TIMER_WAIT.",
+	).Default("250").Int()
+	perfStatementsTextLimit = kingpin.Flag(
+		"collect.perf_schema.statements.text-limit", "Maximum number of characters of digest_text to collect.",
+	).Default("120").Int()
+	perfStatementsTimeLimit = kingpin.Flag(
+		"collect.perf_schema.statements.time-limit", "Ignore digests whose LAST_SEEN is older than this many seconds.",
+	).Default("86400").Int()
+	logFormat = kingpin.Flag(
+		"log.format", "Output format of log messages: logfmt or json.",
+	).Default("logfmt").String()
+	logLevel = kingpin.Flag(
+		"log.level", "Minimum level of messages to log: debug, info, warn, or error. Also bounds what /debug/log retains.",
+	).Default("info").String()
+)
+
+// scrapeTimeoutsTotal counts, per collector, how many scrapes were aborted
+// because their deadline was reached mid-query. It is registered once on
+// the default registerer so the count survives across the short-lived,
+// per-request Exporters created for each /metrics and /probe scrape.
+var scrapeTimeoutsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: exporter,
+		Name:      "scrape_timeout_total",
+		Help:      "Number of scrapes that hit their deadline before a collector finished, by collector.",
+	},
+	[]string{"collector"},
+)
+
+// Metric descriptors.
+var (
+	scrapeDurationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, exporter, "scrape_duration_seconds"),
+		"Duration of a collector scrape.",
+		[]string{"collector"}, nil,
+	)
+	scrapeSuccessDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, exporter, "scrape_success"),
+		"Whether a collector succeeded.",
+		[]string{"collector"}, nil,
+	)
+
+	infoSchemaTableRowsReadDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, informationSchema, "table_rows_read_total"),
+		"The number of rows read from the table.",
+		[]string{"schema", "table"}, nil,
+	)
+	infoSchemaTableRowsChangedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, informationSchema, "table_rows_changed_total"),
+		"The number of rows changed in the table.",
+		[]string{"schema", "table"}, nil,
+	)
+	infoSchemaTableRowsChangedXIndexesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, informationSchema, "table_rows_changed_x_indexes_total"),
+		"The number of rows changed in the table, multiplied by the number of indexes changed.",
+		[]string{"schema", "table"}, nil,
+	)
+
+	infoSchemaQueryResponseTimeSecondsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, informationSchema, "query_response_time_seconds"),
+		"The cumulative number of seconds spent by queries in each query_response_time bucket.",
+		[]string{"le"}, nil,
+	)
+	infoSchemaQueryResponseTimeCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, informationSchema, "query_response_time_read_seconds"),
+		"Histogram of the Percona/MariaDB query_response_time distribution.",
+		nil, nil,
+	)
+
+	globalStatusBufferPoolPagesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, globalStatus, "buffer_pool_pages"),
+		"Innodb buffer pool pages by state.",
+		[]string{"state"}, nil,
+	)
+	globalStatusBufferPoolPagesOpsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, globalStatus, "buffer_pool_pages_total"),
+		"Innodb buffer pool page operations.",
+		[]string{"operation"}, nil,
+	)
+
+	perfStatementsLabels          = []string{"schema", "digest", "digest_text"}
+	perfSchemaStatementsCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "perf_schema", "statements_total"),
+		"Number of times a statement digest has been executed.",
+		perfStatementsLabels, nil,
+	)
+	perfSchemaStatementsTimeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "perf_schema", "statements_seconds_total"),
+		"Time spent executing a statement digest.",
+		perfStatementsLabels, nil,
+	)
+	perfSchemaStatementsRowsExaminedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "perf_schema", "statements_rows_examined_total"),
+		"Rows examined by a statement digest.",
+		perfStatementsLabels, nil,
+	)
+	perfSchemaStatementsRowsSentDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "perf_schema", "statements_rows_sent_total"),
+		"Rows returned by a statement digest.",
+		perfStatementsLabels, nil,
+	)
+	perfSchemaStatementsRowsAffectedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "perf_schema", "statements_rows_affected_total"),
+		"Rows affected by a statement digest.",
+		perfStatementsLabels, nil,
+	)
+	perfSchemaStatementsErrorsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "perf_schema", "statements_errors_total"),
+		"Errors raised by a statement digest.",
+		perfStatementsLabels, nil,
+	)
+	perfSchemaStatementsWarningsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "perf_schema", "statements_warnings_total"),
+		"Warnings raised by a statement digest.",
+		perfStatementsLabels, nil,
+	)
+	perfSchemaStatementsLockTimeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "perf_schema", "statements_lock_seconds"),
+		"Time spent waiting for locks by a statement digest.",
+		perfStatementsLabels, nil,
+	)
+	perfSchemaStatementsTmpTablesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "perf_schema", "statements_tmp_tables"),
+		"Temporary tables created in memory by a statement digest.",
+		perfStatementsLabels, nil,
+	)
+	perfSchemaStatementsTmpDiskTablesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "perf_schema", "statements_tmp_disk_tables"),
+		"Temporary tables created on disk by a statement digest.",
+		perfStatementsLabels, nil,
+	)
+)
+
+// globalStatusPrefixes maps a SHOW GLOBAL STATUS key prefix to the label
+// name and metric descriptor used for the remainder of the key.
+var globalStatusPrefixes = []struct {
+	prefix string
+	label  string
+	desc   *prometheus.Desc
+}{
+	{"com_", "command", prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, globalStatus, "commands_total"),
+		"Number of executions of a given command.", []string{"command"}, nil)},
+	{"handler_", "handler", prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, globalStatus, "handlers_total"),
+		"Number of executions of a given handler.", []string{"handler"}, nil)},
+	{"connection_errors_", "error", prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, globalStatus, "connection_errors_total"),
+		"Number of connection errors of a given type.", []string{"error"}, nil)},
+	{"innodb_rows_", "operation", prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, globalStatus, "innodb_row_ops_total"),
+		"Number of InnoDB row operations of a given type.", []string{"operation"}, nil)},
+	{"performance_schema_", "instrumentation", prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, globalStatus, "performance_schema_lost_total"),
+		"Number of instrumentations that performance_schema lost because of a memory shortage.", []string{"instrumentation"}, nil)},
+}
+
+const (
+	tableStatQuery = `
+		SELECT TABLE_SCHEMA, TABLE_NAME, ROWS_READ, ROWS_CHANGED, ROWS_CHANGED_X_INDEXES
+		  FROM INFORMATION_SCHEMA.TABLE_STATISTICS
+	`
+	queryResponseCheckQuery = `
+		SELECT @@have_response_time_distribution
+	`
+	queryResponseTimeQuery = `
+		SELECT TIME, COUNT, TOTAL
+		  FROM INFORMATION_SCHEMA.QUERY_RESPONSE_TIME
+	`
+	globalStatusQuery = `SHOW GLOBAL STATUS`
+
+	// perfStatementsQuery is built with fmt.Sprintf at scrape time using the
+	// --collect.perf_schema.statements.* flags, in the order
+	// text-limit, time-limit, limit.
+	perfStatementsQuery = `
+		SELECT
+		    ifnull(SCHEMA_NAME, '') AS schema_name,
+		    DIGEST AS digest,
+		    LEFT(DIGEST_TEXT, %d) AS digest_text,
+		    COUNT_STAR AS count_star,
+		    SUM_TIMER_WAIT AS sum_timer_wait,
+		    SUM_ROWS_EXAMINED AS sum_rows_examined,
+		    SUM_ROWS_SENT AS sum_rows_sent,
+		    SUM_ROWS_AFFECTED AS sum_rows_affected,
+		    SUM_ERRORS AS sum_errors,
+		    SUM_WARNINGS AS sum_warnings,
+		    SUM_LOCK_TIME AS sum_lock_time,
+		    SUM_CREATED_TMP_DISK_TABLES AS sum_created_tmp_disk_tables,
+		    SUM_CREATED_TMP_TABLES AS sum_created_tmp_tables
+		  FROM performance_schema.events_statements_summary_by_digest
+		  WHERE SCHEMA_NAME IS NOT NULL
+		    AND LAST_SEEN > DATE_SUB(NOW(), INTERVAL %d SECOND)
+		  ORDER BY SUM_TIMER_WAIT DESC
+		  LIMIT %d
+	`
+)
+
+// oneLine collapses a multi-line SQL query into a single line for logging.
+func oneLine(q string) string {
+	return strings.Join(strings.Fields(q), " ")
+}
+
+// scrapeTableStat collects per-table row access counters exposed by
+// INFORMATION_SCHEMA.TABLE_STATISTICS (a Percona/MariaDB extension). It
+// aborts as soon as ctx is done, leaving any partially read rows behind.
+func scrapeTableStat(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric, logger *slog.Logger) error {
+	logger = logger.With("query", oneLine(tableStatQuery))
+
+	rows, err := db.QueryContext(ctx, tableStatQuery)
+	if err != nil {
+		return fmt.Errorf("querying table statistics: %w", err)
+	}
+	defer rows.Close()
+
+	var schema, table string
+	var rowsRead, rowsChanged, rowsChangedXIndexes uint64
+	var n int
+
+	for rows.Next() {
+		if err := rows.Scan(&schema, &table, &rowsRead, &rowsChanged, &rowsChangedXIndexes); err != nil {
+			return fmt.Errorf("scanning table statistics row: %w", err)
+		}
+		ch <- prometheus.MustNewConstMetric(infoSchemaTableRowsReadDesc, prometheus.CounterValue, float64(rowsRead), schema, table)
+		ch <- prometheus.MustNewConstMetric(infoSchemaTableRowsChangedDesc, prometheus.CounterValue, float64(rowsChanged), schema, table)
+		ch <- prometheus.MustNewConstMetric(infoSchemaTableRowsChangedXIndexesDesc, prometheus.CounterValue, float64(rowsChangedXIndexes), schema, table)
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reading table statistics rows: %w", err)
+	}
+	logger.Debug("collected table statistics", "rows", n)
+	return nil
+}
+
+// scrapeQueryResponseTime collects the Percona/MariaDB query response time
+// distribution, exposing both a running-total counter per bucket boundary
+// (for backwards compatibility with older dashboards) and a proper
+// Prometheus histogram.
+func scrapeQueryResponseTime(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric, logger *slog.Logger) error {
+	logger = logger.With("query", oneLine(queryResponseTimeQuery))
+
+	var queryResponseEnabled int
+	if err := db.QueryRowContext(ctx, queryResponseCheckQuery).Scan(&queryResponseEnabled); err != nil || queryResponseEnabled == 0 {
+		// Feature not present/enabled on this server; nothing to collect.
+		return nil
+	}
+
+	rows, err := db.QueryContext(ctx, queryResponseTimeQuery)
+	if err != nil {
+		return fmt.Errorf("querying query response time distribution: %w", err)
+	}
+	defer rows.Close()
+
+	var length, count, total sql.RawBytes
+	var totalCount uint64
+	var totalTime float64
+	var n int
+	histogramCounts := map[float64]uint64{}
+
+	for rows.Next() {
+		if err := rows.Scan(&length, &count, &total); err != nil {
+			return fmt.Errorf("scanning query response time row: %w", err)
+		}
+		n++
+
+		parsedCount, err := strconv.ParseUint(string(count), 10, 64)
+		if err != nil {
+			return fmt.Errorf("parsing query response time count: %w", err)
+		}
+		totalCount += parsedCount
+
+		// The distribution table has an overflow bucket labelled "TOO LONG"
+		// instead of a numeric upper bound and total; fold it into the +Inf
+		// bucket instead of discarding it.
+		parsedLength, lengthErr := strconv.ParseFloat(string(length), 64)
+		parsedTotal, totalErr := strconv.ParseFloat(string(total), 64)
+		if totalErr == nil {
+			totalTime += parsedTotal
+		}
+
+		label := "+Inf"
+		if lengthErr == nil {
+			label = fmt.Sprintf("%v", parsedLength)
+			histogramCounts[parsedLength] = totalCount
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			infoSchemaQueryResponseTimeSecondsDesc, prometheus.CounterValue, totalTime, label,
+		)
+	}
+
+	ch <- prometheus.MustNewConstHistogram(infoSchemaQueryResponseTimeCountDesc, totalCount, totalTime, histogramCounts)
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reading query response time rows: %w", err)
+	}
+	logger.Debug("collected query response time distribution", "rows", n)
+	return nil
+}
+
+// scrapePerfStatementsSummary collects per-digest statement statistics from
+// performance_schema.events_statements_summary_by_digest, in the spirit of
+// Postgres' pg_stat_statements. The query itself is bounded by the
+// --collect.perf_schema.statements.* flags so a busy server doesn't return
+// an unbounded number of digests or overly long digest_text values.
+func scrapePerfStatementsSummary(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric, logger *slog.Logger) error {
+	query := fmt.Sprintf(perfStatementsQuery, *perfStatementsTextLimit, *perfStatementsTimeLimit, *perfStatementsLimit)
+	logger = logger.With("query", oneLine(query))
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("querying statement summary: %w", err)
+	}
+	defer rows.Close()
+
+	var schemaName, digest, digestText string
+	var countStar, sumTimerWait, sumRowsExamined, sumRowsSent, sumRowsAffected uint64
+	var sumErrors, sumWarnings, sumLockTime, sumCreatedTmpDiskTables, sumCreatedTmpTables uint64
+	var n int
+
+	for rows.Next() {
+		if err := rows.Scan(
+			&schemaName, &digest, &digestText,
+			&countStar, &sumTimerWait,
+			&sumRowsExamined, &sumRowsSent, &sumRowsAffected,
+			&sumErrors, &sumWarnings,
+			&sumLockTime, &sumCreatedTmpDiskTables, &sumCreatedTmpTables,
+		); err != nil {
+			return fmt.Errorf("scanning statement summary row: %w", err)
+		}
+		n++
+
+		ch <- prometheus.MustNewConstMetric(perfSchemaStatementsCountDesc, prometheus.CounterValue, float64(countStar), schemaName, digest, digestText)
+		ch <- prometheus.MustNewConstMetric(perfSchemaStatementsTimeDesc, prometheus.CounterValue, float64(sumTimerWait)/1e12, schemaName, digest, digestText)
+		ch <- prometheus.MustNewConstMetric(perfSchemaStatementsRowsExaminedDesc, prometheus.CounterValue, float64(sumRowsExamined), schemaName, digest, digestText)
+		ch <- prometheus.MustNewConstMetric(perfSchemaStatementsRowsSentDesc, prometheus.CounterValue, float64(sumRowsSent), schemaName, digest, digestText)
+		ch <- prometheus.MustNewConstMetric(perfSchemaStatementsRowsAffectedDesc, prometheus.CounterValue, float64(sumRowsAffected), schemaName, digest, digestText)
+		ch <- prometheus.MustNewConstMetric(perfSchemaStatementsErrorsDesc, prometheus.CounterValue, float64(sumErrors), schemaName, digest, digestText)
+		ch <- prometheus.MustNewConstMetric(perfSchemaStatementsWarningsDesc, prometheus.CounterValue, float64(sumWarnings), schemaName, digest, digestText)
+		ch <- prometheus.MustNewConstMetric(perfSchemaStatementsLockTimeDesc, prometheus.GaugeValue, float64(sumLockTime)/1e12, schemaName, digest, digestText)
+		ch <- prometheus.MustNewConstMetric(perfSchemaStatementsTmpTablesDesc, prometheus.GaugeValue, float64(sumCreatedTmpTables), schemaName, digest, digestText)
+		ch <- prometheus.MustNewConstMetric(perfSchemaStatementsTmpDiskTablesDesc, prometheus.GaugeValue, float64(sumCreatedTmpDiskTables), schemaName, digest, digestText)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reading statement summary rows: %w", err)
+	}
+	logger.Debug("collected statement summary", "rows", n)
+	return nil
+}
+
+// parseStatus converts a SHOW GLOBAL STATUS value into a float64, treating
+// the MySQL booleans ON/OFF/YES/NO as 1/0. ok is false when the value can't
+// be represented numerically (e.g. an empty string such as a blank
+// Ssl_version) and should be skipped.
+func parseStatus(data []byte) (float64, bool) {
+	switch strings.ToUpper(string(data)) {
+	case "YES", "ON":
+		return 1, true
+	case "NO", "OFF":
+		return 0, true
+	}
+	value, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
+
+// scrapeGlobalStatus collects SHOW GLOBAL STATUS, grouping related counters
+// under a single metric name with a label for the distinguishing suffix
+// (e.g. Com_select, Com_insert, ... become mysql_global_status_commands_total
+// with a "command" label) and falling back to one metric per key otherwise.
+func scrapeGlobalStatus(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric, logger *slog.Logger) error {
+	logger = logger.With("query", oneLine(globalStatusQuery))
+
+	rows, err := db.QueryContext(ctx, globalStatusQuery)
+	if err != nil {
+		return fmt.Errorf("querying global status: %w", err)
+	}
+	defer rows.Close()
+
+	var rawKey, rawValue []byte
+	var n int
+	for rows.Next() {
+		if err := rows.Scan(&rawKey, &rawValue); err != nil {
+			return fmt.Errorf("scanning global status row: %w", err)
+		}
+		n++
+		value, ok := parseStatus(rawValue)
+		if !ok {
+			continue
+		}
+		key := strings.ToLower(string(rawKey))
+
+		if strings.HasPrefix(key, "innodb_buffer_pool_pages_") {
+			state := strings.TrimPrefix(key, "innodb_buffer_pool_pages_")
+			switch state {
+			case "data", "dirty", "free", "misc":
+				ch <- prometheus.MustNewConstMetric(globalStatusBufferPoolPagesDesc, prometheus.GaugeValue, value, state)
+			default:
+				ch <- prometheus.MustNewConstMetric(globalStatusBufferPoolPagesOpsDesc, prometheus.CounterValue, value, state)
+			}
+			continue
+		}
+
+		matched := false
+		for _, rule := range globalStatusPrefixes {
+			if strings.HasPrefix(key, rule.prefix) {
+				ch <- prometheus.MustNewConstMetric(rule.desc, prometheus.CounterValue, value, strings.TrimPrefix(key, rule.prefix))
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(prometheus.BuildFQName(namespace, globalStatus, key), key+" global status.", nil, nil),
+			prometheus.UntypedValue, value,
+		)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reading global status rows: %w", err)
+	}
+	logger.Debug("collected global status", "rows", n)
+	return nil
+}
+
+// mycnfClient loads the [client] section of a MySQL client configuration
+// file (either a path or its raw contents).
+func mycnfClient(config interface{}) (*ini.Section, error) {
+	cfg, err := ini.Load(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading ini file: %s", err)
+	}
+	return cfg.Section("client"), nil
+}
+
+// credentialsFromClient extracts the username and password from an
+// already-loaded [client] ini.Section.
+func credentialsFromClient(config interface{}, client *ini.Section) (user, password string, err error) {
+	user = client.Key("user").String()
+	if user == "" {
+		return "", "", fmt.Errorf("no user specified under [client] in %v", config)
+	}
+	password = client.Key("password").String()
+	if password == "" {
+		return "", "", fmt.Errorf("no password specified under [client] in %v", config)
+	}
+	return user, password, nil
+}
+
+// mycnfCredentials extracts the username and password from a MySQL client
+// configuration file's [client] section, for callers that supply their own
+// connection host (e.g. the /probe endpoint's target) instead of using the
+// host/port/socket also found there.
+func mycnfCredentials(config interface{}) (user, password string, err error) {
+	client, err := mycnfClient(config)
+	if err != nil {
+		return "", "", err
+	}
+	return credentialsFromClient(config, client)
+}
+
+// parseMycnf reads a MySQL client configuration file (either a path or its
+// raw contents) and builds a go-sql-driver DSN from the [client] section,
+// preferring a unix socket over tcp when both are present.
+func parseMycnf(config interface{}) (string, error) {
+	client, err := mycnfClient(config)
+	if err != nil {
+		return "", err
+	}
+	user, password, err := credentialsFromClient(config, client)
+	if err != nil {
+		return "", err
+	}
+	socket := client.Key("socket").String()
+	if socket != "" {
+		return fmt.Sprintf("%s:%s@unix(%s)/", user, password, socket), nil
+	}
+	host := client.Key("host").MustString("localhost")
+	port := client.Key("port").MustUint(3306)
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/", user, password, host, port), nil
+}
+
+// Exporter collects MySQL metrics for a single target DSN and implements
+// prometheus.Collector. ctx bounds every query run during Collect, so a
+// stuck information_schema or performance_schema query cannot stall the
+// scrape past Prometheus' own scrape_timeout.
+type Exporter struct {
+	dsn    string
+	ctx    context.Context
+	logger *slog.Logger
+	mutex  sync.Mutex
+}
+
+// NewExporter returns a new Exporter scraping the given DSN. ctx should
+// carry a deadline derived from the Prometheus scrape timeout; pass
+// context.Background() when no deadline is available (e.g. in tests).
+// logger should already carry any fields identifying this scrape, such as
+// "target" and "trace_id".
+func NewExporter(ctx context.Context, dsn string, logger *slog.Logger) *Exporter {
+	return &Exporter{dsn: dsn, ctx: ctx, logger: logger}
+}
+
+// Describe implements prometheus.Collector.
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- scrapeDurationDesc
+	ch <- scrapeSuccessDesc
+}
+
+// Collect implements prometheus.Collector.
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.scrape(ch)
+}
+
+func (e *Exporter) scrape(ch chan<- prometheus.Metric) {
+	db, err := sql.Open("mysql", e.dsn)
+	if err != nil {
+		e.logger.Error("error opening connection to database", "err", err)
+		return
+	}
+	defer db.Close()
+
+	if err := db.PingContext(e.ctx); err != nil {
+		e.logger.Error("error pinging database", "err", err)
+		return
+	}
+
+	collectors := []struct {
+		name    string
+		timeout time.Duration
+		scrape  func(context.Context, *sql.DB, chan<- prometheus.Metric, *slog.Logger) error
+	}{
+		{"global_status", *globalStatusTimeout, scrapeGlobalStatus},
+		{"info_schema.tablestats", *tableStatTimeout, scrapeTableStat},
+		{"info_schema.query_response_time", *queryResponseTimeTimeout, scrapeQueryResponseTime},
+		{"perf_schema.statements", *perfStatementsTimeout, scrapePerfStatementsSummary},
+	}
+	for _, c := range collectors {
+		ctx := e.ctx
+		cancel := func() {}
+		if c.timeout > 0 {
+			ctx, cancel = context.WithTimeout(e.ctx, c.timeout)
+		}
+		clog := e.logger.With("collector", c.name)
+
+		begin := time.Now()
+		err := c.scrape(ctx, db, ch, clog)
+		duration := time.Since(begin).Seconds()
+		cancel()
+
+		success := 1.0
+		if err != nil {
+			clog.Error("error scraping collector", "err", err, "duration_ms", duration*1000)
+			success = 0
+			if errors.Is(err, context.DeadlineExceeded) {
+				scrapeTimeoutsTotal.WithLabelValues(c.name).Inc()
+			}
+		} else {
+			clog.Debug("scrape succeeded", "duration_ms", duration*1000)
+		}
+		ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, duration, c.name)
+		ch <- prometheus.MustNewConstMetric(scrapeSuccessDesc, prometheus.GaugeValue, success, c.name)
+	}
+}
+
+// scrapeContext derives a bounded context for a single scrape from the
+// X-Prometheus-Scrape-Timeout-Seconds header Prometheus attaches to every
+// scrape request, minus --scrape.timeout-offset. It falls back to the
+// request's own (unbounded) context when the header is absent or
+// unparsable.
+func scrapeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	header := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	timeoutSeconds, err := strconv.ParseFloat(header, 64)
+	if err != nil {
+		return context.WithCancel(r.Context())
+	}
+	timeoutSeconds -= *scrapeTimeoutOffset
+	if timeoutSeconds <= 0 {
+		return context.WithCancel(r.Context())
+	}
+	return context.WithTimeout(r.Context(), time.Duration(timeoutSeconds*float64(time.Second)))
+}
+
+// metricsHandler builds a fresh Exporter (and a fresh registry) for every
+// request so each scrape gets its own deadline derived from scrapeContext,
+// merging in the default registry for the exporter's own process metrics.
+func metricsHandler(dsn string, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := scrapeContext(r)
+		defer cancel()
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(NewExporter(ctx, dsn, logger))
+
+		promhttp.HandlerFor(prometheus.Gatherers{prometheus.DefaultGatherer, registry}, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	}
+}
+
+func main() {
+	kingpin.Version(version.Print("mysqld_exporter"))
+	kingpin.HelpFlag.Short('h')
+	kingpin.Parse()
+
+	debugLog := newDebugLogBuffer(debugLogBufferSize)
+	logger := newLogger(*logFormat, *logLevel, debugLog)
+
+	dsn := os.Getenv("DATA_SOURCE_NAME")
+	if dsn == "" {
+		var err error
+		dsn, err = parseMycnf(*configMycnf)
+		if err != nil {
+			logger.Error("error determining DSN", "err", err)
+			os.Exit(1)
+		}
+	}
+
+	prometheus.MustRegister(version.NewCollector("mysqld_exporter"))
+	prometheus.MustRegister(scrapeTimeoutsTotal)
+
+	logger.Info("starting mysqld_exporter", "version", version.Info())
+	logger.Info("build context", "context", version.BuildContext())
+
+	if *configFile != "" {
+		reloader, err := NewConfigReloader(*configFile, logger)
+		if err != nil {
+			logger.Error("error loading config file", "err", err)
+			os.Exit(1)
+		}
+		go reloader.Watch(context.Background())
+
+		http.HandleFunc("/probe", func(w http.ResponseWriter, r *http.Request) {
+			probeHandler(w, r, reloader.Get(), logger)
+		})
+		http.HandleFunc("/-/reload", reloader.ReloadHandler)
+		logger.Info("multi-target /probe endpoint enabled", "config.file", *configFile)
+	}
+
+	http.Handle(*metricsPath, metricsHandler(dsn, logger))
+	http.Handle("/debug/log", debugLog)
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html>
+			<head><title>MySQLd Exporter</title></head>
+			<body>
+			<h1>MySQLd Exporter</h1>
+			<p><a href='` + *metricsPath + `'>Metrics</a></p>
+			</body>
+			</html>`))
+	})
+
+	logger.Info("listening", "address", *listenAddress)
+	if err := http.ListenAndServe(*listenAddress, nil); err != nil {
+		logger.Error("server stopped", "err", err)
+		os.Exit(1)
+	}
+}
@@ -1,8 +1,14 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
@@ -10,6 +16,12 @@ import (
 	"gopkg.in/DATA-DOG/go-sqlmock.v1"
 )
 
+// testLogger returns a *slog.Logger that discards everything it's given,
+// for scrape* calls in tests that don't care about log output.
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
 type LabelMap map[string]string
 
 type MetricResult struct {
@@ -57,7 +69,7 @@ func Test_scrapeTableStat(t *testing.T) {
 
 	ch := make(chan prometheus.Metric)
 	go func() {
-		if err = scrapeTableStat(db, ch); err != nil {
+		if err = scrapeTableStat(context.Background(), db, ch, testLogger()); err != nil {
 			t.Errorf("error calling function on test: %s", err)
 		}
 		close(ch)
@@ -115,7 +127,7 @@ func Test_scrapeQueryResponseTime(t *testing.T) {
 
 	ch := make(chan prometheus.Metric)
 	go func() {
-		if err = scrapeQueryResponseTime(db, ch); err != nil {
+		if err = scrapeQueryResponseTime(context.Background(), db, ch, testLogger()); err != nil {
 			t.Errorf("error calling function on test: %s", err)
 		}
 		close(ch)
@@ -297,7 +309,7 @@ func Test_scrapeGlobalStatus(t *testing.T) {
 
 	ch := make(chan prometheus.Metric)
 	go func() {
-		if err = scrapeGlobalStatus(db, ch); err != nil {
+		if err = scrapeGlobalStatus(context.Background(), db, ch, testLogger()); err != nil {
 			t.Errorf("error calling function on test: %s", err)
 		}
 		close(ch)
@@ -328,3 +340,179 @@ func Test_scrapeGlobalStatus(t *testing.T) {
 		t.Errorf("there were unfulfilled expections: %s", err)
 	}
 }
+
+func Test_scrapePerfStatementsSummary(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{
+		"schema_name", "digest", "digest_text",
+		"count_star", "sum_timer_wait",
+		"sum_rows_examined", "sum_rows_sent", "sum_rows_affected",
+		"sum_errors", "sum_warnings",
+		"sum_lock_time", "sum_created_tmp_disk_tables", "sum_created_tmp_tables",
+	}
+	rows := sqlmock.NewRows(columns).
+		AddRow("mysql", "abc123", "SELECT * FROM `user`", 5, 2000000000000, 50, 10, 0, 1, 2, 1000000000000, 3, 4)
+	query := fmt.Sprintf(perfStatementsQuery, *perfStatementsTextLimit, *perfStatementsTimeLimit, *perfStatementsLimit)
+	mock.ExpectQuery(regexp.QuoteMeta(sanitizeQuery(query))).WillReturnRows(rows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapePerfStatementsSummary(context.Background(), db, ch, testLogger()); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	labels := LabelMap{"schema": "mysql", "digest": "abc123", "digest_text": "SELECT * FROM `user`"}
+	expected := []MetricResult{
+		{labels: labels, value: 5, metricType: dto.MetricType_COUNTER},
+		{labels: labels, value: 2, metricType: dto.MetricType_COUNTER},
+		{labels: labels, value: 50, metricType: dto.MetricType_COUNTER},
+		{labels: labels, value: 10, metricType: dto.MetricType_COUNTER},
+		{labels: labels, value: 0, metricType: dto.MetricType_COUNTER},
+		{labels: labels, value: 1, metricType: dto.MetricType_COUNTER},
+		{labels: labels, value: 2, metricType: dto.MetricType_COUNTER},
+		{labels: labels, value: 1, metricType: dto.MetricType_GAUGE},
+		{labels: labels, value: 4, metricType: dto.MetricType_GAUGE},
+		{labels: labels, value: 3, metricType: dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, func() {
+		for _, expect := range expected {
+			got := readMetric(<-ch)
+			convey.So(got, convey.ShouldResemble, expect)
+		}
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expections: %s", err)
+	}
+}
+
+func Test_scrapeTableStat_Timeout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"TABLE_SCHEMA", "TABLE_NAME", "ROWS_READ", "ROWS_CHANGED", "ROWS_CHANGED_X_INDEXES"}
+	mock.ExpectQuery(sanitizeQuery(tableStatQuery)).
+		WillDelayFor(50 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("mysql", "db", 238, 0, 8))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		for range ch {
+		}
+	}()
+	err = scrapeTableStat(ctx, db, ch, testLogger())
+	close(ch)
+
+	convey.Convey("Deadline is honored", t, func() {
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(ctx.Err(), convey.ShouldResemble, context.DeadlineExceeded)
+	})
+}
+
+func Test_scrapeGlobalStatus_Timeout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"Variable_name", "Value"}
+	mock.ExpectQuery(sanitizeQuery(globalStatusQuery)).
+		WillDelayFor(50 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("Uptime", "10"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		for range ch {
+		}
+	}()
+	err = scrapeGlobalStatus(ctx, db, ch, testLogger())
+	close(ch)
+
+	convey.Convey("Deadline is honored", t, func() {
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(ctx.Err(), convey.ShouldResemble, context.DeadlineExceeded)
+	})
+}
+
+func Test_scrapeQueryResponseTime_Timeout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(queryResponseCheckQuery).WillReturnRows(sqlmock.NewRows([]string{""}).AddRow(1))
+	mock.ExpectQuery(sanitizeQuery(queryResponseTimeQuery)).
+		WillDelayFor(50 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows([]string{"TIME", "COUNT", "TOTAL"}).AddRow(0.000001, 124, 0.000000))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		for range ch {
+		}
+	}()
+	err = scrapeQueryResponseTime(ctx, db, ch, testLogger())
+	close(ch)
+
+	convey.Convey("Deadline is honored", t, func() {
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(ctx.Err(), convey.ShouldResemble, context.DeadlineExceeded)
+	})
+}
+
+func Test_scrapePerfStatementsSummary_Timeout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	query := fmt.Sprintf(perfStatementsQuery, *perfStatementsTextLimit, *perfStatementsTimeLimit, *perfStatementsLimit)
+	columns := []string{
+		"schema_name", "digest", "digest_text",
+		"count_star", "sum_timer_wait",
+		"sum_rows_examined", "sum_rows_sent", "sum_rows_affected",
+		"sum_errors", "sum_warnings",
+		"sum_lock_time", "sum_created_tmp_disk_tables", "sum_created_tmp_tables",
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(sanitizeQuery(query))).
+		WillDelayFor(50 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("mysql", "abc123", "SELECT * FROM `user`", 5, 2000000000000, 50, 10, 0, 1, 2, 1000000000000, 3, 4))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		for range ch {
+		}
+	}()
+	err = scrapePerfStatementsSummary(ctx, db, ch, testLogger())
+	close(ch)
+
+	convey.Convey("Deadline is honored", t, func() {
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(ctx.Err(), convey.ShouldResemble, context.DeadlineExceeded)
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// probeHandler implements the multi-target /probe endpoint. It accepts a
+// mandatory ?target=host:port and an optional ?auth_module=name, looks up
+// credentials for auth_module in cfg (falling back to the username/password
+// in --config.my-cnf when no module is given), and scrapes that one target
+// into its own registry so the response only ever contains metrics for it,
+// each carrying an "instance" label set to target. Every request is tagged
+// with a trace ID, echoed back in the X-Trace-Id header, so its log lines
+// can be pulled back out of /debug/log afterwards.
+func probeHandler(w http.ResponseWriter, r *http.Request, cfg *Config, logger *slog.Logger) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	traceID := newTraceID()
+	w.Header().Set("X-Trace-Id", traceID)
+	logger = logger.With("target", target, "trace_id", traceID)
+
+	dsn, err := probeDSN(r, cfg)
+	if err != nil {
+		logger.Error("error resolving credentials for probe", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := scrapeContext(r)
+	defer cancel()
+
+	registry := prometheus.NewRegistry()
+	instanceRegistry := prometheus.WrapRegistererWith(prometheus.Labels{"instance": target}, registry)
+	instanceRegistry.MustRegister(NewExporter(ctx, dsn, logger))
+
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// probeDSN resolves the DSN to scrape for a /probe request: via the named
+// auth_module if one is given, otherwise via the username/password in
+// --config.my-cnf, always dialing the requested target rather than
+// whatever host the mycnf's own [client] section names.
+func probeDSN(r *http.Request, cfg *Config) (string, error) {
+	target := r.URL.Query().Get("target")
+
+	if authModule := r.URL.Query().Get("auth_module"); authModule != "" {
+		return cfg.DSN(authModule, target)
+	}
+
+	user, password, err := mycnfCredentials(*configMycnf)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%s@tcp(%s)/", user, password, target), nil
+}
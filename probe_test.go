@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func Test_probeHandler(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	convey.Convey("Given a probe request", t, func() {
+		convey.Convey("With auth_module set, it resolves the DSN via cfg.DSN", func() {
+			cfg := &Config{AuthModules: map[string]AuthModule{
+				"client": {Type: "userpass", UserPass: UserPass{Username: "root", Password: "abc123"}},
+			}}
+			req := httptest.NewRequest(http.MethodGet, "/probe?target=db.example.com:3306&auth_module=client", nil)
+			rec := httptest.NewRecorder()
+
+			dsn, err := probeDSN(req, cfg)
+
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(dsn, convey.ShouldEqual, "root:abc123@tcp(db.example.com:3306)/")
+
+			probeHandler(rec, req, cfg, logger)
+			convey.So(rec.Header().Get("X-Trace-Id"), convey.ShouldNotBeEmpty)
+		})
+
+		convey.Convey("With auth_module unset, it falls back to --config.my-cnf credentials but still dials target", func() {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "my.cnf")
+			mycnf := `
+				[client]
+				user = root
+				password = abc123
+				host = 1.2.3.4
+				port = 3307
+			`
+			if err := os.WriteFile(path, []byte(mycnf), 0644); err != nil {
+				t.Fatalf("error writing temp my.cnf: %s", err)
+			}
+			old := *configMycnf
+			*configMycnf = path
+			defer func() { *configMycnf = old }()
+
+			req := httptest.NewRequest(http.MethodGet, "/probe?target=db.example.com:3306", nil)
+
+			dsn, err := probeDSN(req, &Config{})
+
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(dsn, convey.ShouldEqual, "root:abc123@tcp(db.example.com:3306)/")
+		})
+
+		convey.Convey("With target missing, it is rejected before any DSN is resolved", func() {
+			req := httptest.NewRequest(http.MethodGet, "/probe", nil)
+			rec := httptest.NewRecorder()
+
+			probeHandler(rec, req, &Config{}, logger)
+
+			convey.So(rec.Code, convey.ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}